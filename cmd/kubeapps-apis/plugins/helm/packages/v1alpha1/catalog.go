@@ -0,0 +1,71 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"github.com/kubeapps/kubeapps/pkg/ociregistry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ChartCatalog is a source of Helm charts this plugin can list as available packages. The
+// Postgres-backed utils.AssetManager paginates via GetAvailablePackageSummaries directly, since
+// its cursor-based keyset pagination doesn't generalize to other catalog kinds; a ChartCatalog
+// instead returns its full summary list and is merged in on the first page only.
+type ChartCatalog interface {
+	// ListAvailablePackageSummaries returns every available package this catalog knows about.
+	ListAvailablePackageSummaries(ctx context.Context) ([]*corev1.AvailablePackageSummary, error)
+}
+
+// ociRegistryPluginDetail records the plugin name/version advertised for packages discovered
+// via an OCIRegistryCatalog, distinguishing them from the Postgres-backed ones.
+var ociRegistryPluginDetail = &plugins.Plugin{Name: "helm.packages.ociregistry", Version: "v1alpha1"}
+
+// OCIRegistryCatalog is a ChartCatalog backed by a single OCI Distribution v2 registry,
+// discovering Helm charts published to it as OCI artifacts (Helm 3.8+).
+type OCIRegistryCatalog struct {
+	catalog *ociregistry.Catalog
+}
+
+// NewOCIRegistryCatalog returns a ChartCatalog that lists the Helm charts published to host,
+// authenticating via keychain.
+func NewOCIRegistryCatalog(host string, keychain authn.Keychain) *OCIRegistryCatalog {
+	return &OCIRegistryCatalog{catalog: ociregistry.NewCatalog(host, keychain)}
+}
+
+// ListAvailablePackageSummaries lists every Helm chart published to the registry as an
+// AvailablePackageSummary, tagged with the OCI registry plugin identity.
+func (c *OCIRegistryCatalog) ListAvailablePackageSummaries(ctx context.Context) ([]*corev1.AvailablePackageSummary, error) {
+	charts, err := c.catalog.ListCharts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list charts from OCI registry: %w", err)
+	}
+
+	summaries := make([]*corev1.AvailablePackageSummary, 0, len(charts))
+	for _, chart := range charts {
+		pkg, err := AvailablePackageSummaryFromChart(chart)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to parse chart %q: %v", chart.ID, err)
+		}
+		pkg.AvailablePackageRef.Plugin = ociRegistryPluginDetail
+		summaries = append(summaries, pkg)
+	}
+	return summaries, nil
+}
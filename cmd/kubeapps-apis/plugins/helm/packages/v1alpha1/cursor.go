@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// pageCursor is the last-seen (repo_namespace, chart_id) pair a keyset-paginated query resumes
+// after, letting GetAvailablePackageSummaries avoid OFFSET on a potentially huge result set.
+type pageCursor struct {
+	RepoNamespace string `json:"repoNamespace"`
+	ChartID       string `json:"chartId"`
+}
+
+// encodeCursor returns an opaque, HMAC-signed page token for c so that callers cannot forge or
+// tamper with a cursor to skip the RBAC/namespace checks applied to the original request.
+func encodeCursor(secret []byte, c pageCursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode page cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + signCursor(secret, payload), nil
+}
+
+// decodeCursor verifies and decodes a page token produced by encodeCursor, returning an error
+// if the token is malformed or its signature does not match, e.g. because it was tampered with.
+func decodeCursor(secret []byte, token string) (*pageCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	sepIdx := strings.IndexByte(token, '.')
+	if sepIdx < 0 {
+		return nil, fmt.Errorf("malformed page token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:sepIdx])
+	if err != nil {
+		return nil, fmt.Errorf("malformed page token: %w", err)
+	}
+	if !hmac.Equal([]byte(token[sepIdx+1:]), []byte(signCursor(secret, payload))) {
+		return nil, fmt.Errorf("page token signature does not match")
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("malformed page token: %w", err)
+	}
+	return &c, nil
+}
+
+func signCursor(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	secret := []byte("test-secret")
+	want := pageCursor{RepoNamespace: "my-ns", ChartID: "my-ns/my-chart"}
+
+	token, err := encodeCursor(secret, want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := decodeCursor(secret, token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *got != want {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+}
+
+func TestDecodeCursorEmptyToken(t *testing.T) {
+	got, err := decodeCursor([]byte("test-secret"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("got: %+v, want: nil", got)
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	secret := []byte("test-secret")
+	token, err := encodeCursor(secret, pageCursor{RepoNamespace: "my-ns", ChartID: "my-ns/my-chart"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "tampered signature", token: token + "x"},
+		{name: "wrong secret", token: token},
+		{name: "missing signature", token: "bm90LWpzb24"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			secretToUse := secret
+			if tc.name == "wrong secret" {
+				secretToUse = []byte("a-different-secret")
+			}
+			if _, err := decodeCursor(secretToUse, tc.token); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+}
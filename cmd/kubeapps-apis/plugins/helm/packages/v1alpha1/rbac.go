@@ -0,0 +1,216 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// secretsReadResource is the only permission GetAvailablePackageSummaries actually relies on:
+// the ability to read chart repo credentials stored as Secrets in the candidate namespace.
+var secretsReadResource = authorizationv1.ResourceAttributes{Group: "", Resource: "secrets", Verb: "get"}
+
+// rbacCacheTTL bounds how long a SelfSubjectRulesReview result is trusted for a given
+// (token, namespace) pair before it is re-checked against the API server.
+const rbacCacheTTL = 30 * time.Second
+
+// rbacCacheEntry is a cached SelfSubjectRulesReview result for one (token, namespace) pair.
+type rbacCacheEntry struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+// rbacCache caches namespace access decisions per bearer token so that repeated
+// GetAvailablePackageSummaries calls from the same user don't re-issue a
+// SelfSubjectRulesReview for every request.
+type rbacCache struct {
+	mu      sync.Mutex
+	entries map[string]rbacCacheEntry
+	hits    uint64
+	misses  uint64
+}
+
+func newRBACCache() *rbacCache {
+	return &rbacCache{entries: map[string]rbacCacheEntry{}}
+}
+
+// RBACCacheStats summarizes hit/miss counts so operators can verify the cache is earning its
+// keep. Exposed over HTTP via Server.ServeRBACCacheStats.
+type RBACCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// RBACCacheStats reports the current hit/miss counters and entry count of the RBAC cache.
+func (s *Server) RBACCacheStats() RBACCacheStats {
+	cache := s.cache()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return RBACCacheStats{
+		Hits:   cache.hits,
+		Misses: cache.misses,
+		Size:   len(cache.entries),
+	}
+}
+
+// ServeRBACCacheStats is an http.HandlerFunc that writes the current RBACCacheStats as JSON.
+// Mount it on the process's debug mux (e.g. alongside pprof), such as at "/debug/rbac-cache",
+// so operators can verify cache hit rates without a restart.
+func (s *Server) ServeRBACCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.RBACCacheStats())
+}
+
+func (c *rbacCache) get(key string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return false, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		c.misses++
+		return false, false
+	}
+	c.hits++
+	return entry.allowed, true
+}
+
+func (c *rbacCache) set(key string, allowed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.sweepExpiredLocked(now)
+	c.entries[key] = rbacCacheEntry{allowed: allowed, expiresAt: now.Add(rbacCacheTTL)}
+}
+
+// sweepExpiredLocked removes every entry that expired as of now. Called with mu held, on every
+// set, so the map can't grow unbounded from tokens that are cached once and never looked up
+// again (get's own eviction only reclaims keys that are actually re-queried after expiry).
+func (c *rbacCache) sweepExpiredLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// bearerTokenHash returns a stable cache-key fragment for the bearer token on ctx, without
+// retaining the token itself in memory.
+func bearerTokenHash(ctx context.Context) string {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hasAccessToNamespaces reports whether the caller can read Secrets in every one of namespaces.
+// It evaluates a single cached SelfSubjectRulesReview per namespace rather than issuing a
+// SelfSubjectAccessReview per namespace per request, falling back to the SSAR-per-namespace
+// path if the API server rejects SelfSubjectRulesReview (e.g. an older cluster).
+func (s *Server) hasAccessToNamespaces(ctx context.Context, typedClient kubernetes.Interface, namespaces []string) (bool, error) {
+	cache := s.cache()
+	tokenHash := bearerTokenHash(ctx)
+
+	for _, namespace := range namespaces {
+		cacheKey := tokenHash + "/" + namespace
+		if allowed, ok := cache.get(cacheKey); ok {
+			if !allowed {
+				return false, nil
+			}
+			continue
+		}
+
+		allowed, err := s.checkNamespaceViaSSRR(ctx, typedClient, namespace)
+		if err != nil {
+			// The cluster may not support SelfSubjectRulesReview; fall back to the
+			// original per-namespace SelfSubjectAccessReview check.
+			allowed, err = s.hasAccessToNamespace(ctx, typedClient, namespace)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		cache.set(cacheKey, allowed)
+		if !allowed {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// checkNamespaceViaSSRR issues a single SelfSubjectRulesReview for namespace and evaluates the
+// returned ResourceRules locally against secretsReadResource.
+func (s *Server) checkNamespaceViaSSRR(ctx context.Context, typedClient kubernetes.Interface, namespace string) (bool, error) {
+	review, err := typedClient.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, &authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "unable to list rules for namespace %q: %v", namespace, err)
+	}
+	return rulesAllow(review.Status.ResourceRules, secretsReadResource), nil
+}
+
+// rulesAllow reports whether any rule in rules permits want, treating the wildcard "*" in
+// APIGroups/Resources/Verbs the way Kubernetes RBAC itself does. A rule scoped to specific
+// ResourceNames is skipped: it only grants access to those named objects, not the general
+// access this check requires.
+func rulesAllow(rules []authorizationv1.ResourceRule, want authorizationv1.ResourceAttributes) bool {
+	for _, rule := range rules {
+		if len(rule.ResourceNames) > 0 {
+			continue
+		}
+		if !stringSliceContainsAny(rule.APIGroups, want.Group) {
+			continue
+		}
+		if !stringSliceContainsAny(rule.Resources, want.Resource) {
+			continue
+		}
+		if !stringSliceContainsAny(rule.Verbs, want.Verb) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func stringSliceContainsAny(haystack []string, needle string) bool {
+	for _, v := range haystack {
+		if v == "*" || v == needle {
+			return true
+		}
+	}
+	return false
+}
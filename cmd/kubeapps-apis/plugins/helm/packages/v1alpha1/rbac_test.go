@@ -0,0 +1,149 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	typfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRulesAllow(t *testing.T) {
+	want := authorizationv1.ResourceAttributes{Group: "", Resource: "secrets", Verb: "get"}
+
+	testCases := []struct {
+		name  string
+		rules []authorizationv1.ResourceRule
+		want  bool
+	}{
+		{
+			name:  "no rules means no access",
+			rules: nil,
+			want:  false,
+		},
+		{
+			name: "an exact match rule grants access",
+			rules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+			},
+			want: true,
+		},
+		{
+			name: "a wildcard rule grants access",
+			rules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			},
+			want: true,
+		},
+		{
+			name: "a rule for a different resource does not grant access",
+			rules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+			},
+			want: false,
+		},
+		{
+			name: "a rule scoped to specific resource names does not grant the general access",
+			rules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}, ResourceNames: []string{"my-secret"}},
+			},
+			want: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := rulesAllow(tc.rules, want); got != tc.want {
+				t.Errorf("got: %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHasAccessToNamespacesFallsBackToSSAR(t *testing.T) {
+	clientSet := typfake.NewSimpleClientset()
+	clientSet.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("SelfSubjectRulesReview not supported")
+	})
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SelfSubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: true},
+		}, nil
+	})
+
+	s := &Server{}
+	allowed, err := s.hasAccessToNamespaces(context.Background(), clientSet, []string{"my-ns"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Errorf("got: false, want: true")
+	}
+}
+
+func TestHasAccessToNamespacesCachesResult(t *testing.T) {
+	clientSet := typfake.NewSimpleClientset()
+	calls := 0
+	clientSet.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		return true, &authorizationv1.SelfSubjectRulesReview{
+			Status: authorizationv1.SubjectRulesReviewStatus{
+				ResourceRules: []authorizationv1.ResourceRule{
+					{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+				},
+			},
+		}, nil
+	})
+
+	s := &Server{}
+	for i := 0; i < 3; i++ {
+		if _, err := s.hasAccessToNamespaces(context.Background(), clientSet, []string{"my-ns"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("got %d SelfSubjectRulesReview calls, want 1 (later calls should hit the cache)", calls)
+	}
+	if got, want := s.RBACCacheStats().Hits, uint64(2); got != want {
+		t.Errorf("got %d cache hits, want %d", got, want)
+	}
+}
+
+func TestRBACCacheEvictsExpiredEntries(t *testing.T) {
+	cache := newRBACCache()
+	cache.entries["stale"] = rbacCacheEntry{allowed: true, expiresAt: time.Now().Add(-time.Minute)}
+
+	cache.set("fresh", true)
+	if _, ok := cache.entries["stale"]; ok {
+		t.Errorf("expected the expired entry to be swept on set, but it is still present")
+	}
+	if got, want := len(cache.entries), 1; got != want {
+		t.Errorf("got %d entries, want %d", got, want)
+	}
+
+	cache.entries["also-stale"] = rbacCacheEntry{allowed: true, expiresAt: time.Now().Add(-time.Minute)}
+	if _, ok := cache.get("also-stale"); ok {
+		t.Errorf("expected an expired entry to be reported as a miss")
+	}
+	if _, ok := cache.entries["also-stale"]; ok {
+		t.Errorf("expected the expired entry to be evicted on get")
+	}
+}
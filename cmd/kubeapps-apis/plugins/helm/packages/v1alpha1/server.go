@@ -0,0 +1,505 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/kubeapps/kubeapps/cmd/assetsvc/pkg/utils"
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/kubeapps/kubeapps/pkg/chart/models"
+	"github.com/kubeapps/kubeapps/pkg/statuscheck"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog/v2"
+)
+
+// pluginDetail records the plugin name/version advertised on every
+// AvailablePackageReference/InstalledPackageReference this plugin returns.
+var pluginDetail = &plugins.Plugin{Name: "helm.packages", Version: "v1alpha1"}
+
+// Server implements the helm packages v1alpha1 PackagesService.
+type Server struct {
+	corev1.UnimplementedPackagesServiceServer
+
+	clientGetter             server.KubernetesClientGetter
+	manager                  utils.AssetManager
+	globalPackagingNamespace string
+
+	// catalogs lists additional, non-Postgres chart sources (e.g. OCI registries) merged into
+	// GetAvailablePackageSummaries alongside the charts paginated from manager.
+	catalogs []ChartCatalog
+
+	rbacCacheOnce sync.Once
+	rbacCache     *rbacCache
+
+	cursorSecretOnce sync.Once
+	cursorSecret     []byte
+}
+
+// NewServer returns a Server backed by the given manager and global packaging namespace. Any
+// additional catalogs (e.g. NewOCIRegistryCatalog) are merged into GetAvailablePackageSummaries
+// alongside the Postgres-backed charts from manager.
+func NewServer(clientGetter server.KubernetesClientGetter, manager utils.AssetManager, globalPackagingNamespace string, catalogs ...ChartCatalog) *Server {
+	return &Server{
+		clientGetter:             clientGetter,
+		manager:                  manager,
+		globalPackagingNamespace: globalPackagingNamespace,
+		catalogs:                 catalogs,
+		rbacCache:                newRBACCache(),
+	}
+}
+
+// secret lazily generates a random HMAC key to sign page cursors with, so a Server built as a
+// struct literal (as the tests in this package do) still gets tamper-proof cursors.
+func (s *Server) secret() []byte {
+	s.cursorSecretOnce.Do(func() {
+		if s.cursorSecret == nil {
+			s.cursorSecret = make([]byte, 32)
+			if _, err := rand.Read(s.cursorSecret); err != nil {
+				log.Fatalf("unable to generate page cursor secret: %v", err)
+			}
+		}
+	})
+	return s.cursorSecret
+}
+
+// cache lazily initializes the RBAC cache, so a Server built as a struct literal (as the
+// tests in this package do) works without calling NewServer.
+func (s *Server) cache() *rbacCache {
+	s.rbacCacheOnce.Do(func() {
+		if s.rbacCache == nil {
+			s.rbacCache = newRBACCache()
+		}
+	})
+	return s.rbacCache
+}
+
+// GetManager returns the configured asset manager or an internal error if none is set.
+func (s *Server) GetManager() (utils.AssetManager, error) {
+	if s.manager == nil {
+		return nil, status.Errorf(codes.Internal, "server not configured with manager")
+	}
+	return s.manager, nil
+}
+
+// GetClients ensures a clientGetter is configured and returns the typed and dynamic clients it produces.
+func (s *Server) GetClients(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+	if s.clientGetter == nil {
+		return nil, nil, status.Errorf(codes.Internal, "server not configured with clientGetter")
+	}
+	typedClient, dynamicClient, err := s.clientGetter(ctx)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.FailedPrecondition, "unable to get clients: %v", err)
+	}
+	return typedClient, dynamicClient, nil
+}
+
+// hasAccessToNamespace checks whether the caller is allowed to read secrets in namespace,
+// which is the permission the plugin actually relies on to read chart repo credentials.
+func (s *Server) hasAccessToNamespace(ctx context.Context, typedClient kubernetes.Interface, namespace string) (bool, error) {
+	res, err := typedClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:     "",
+				Resource:  "secrets",
+				Verb:      "get",
+				Namespace: namespace,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "unable to check access to namespace %q: %v", namespace, err)
+	}
+	return res.Status.Allowed, nil
+}
+
+// AvailablePackageSummaryFromChart builds an AvailablePackageSummary from a chart model,
+// returning an internal error if the chart is missing the fields required to do so.
+func AvailablePackageSummaryFromChart(chart *models.Chart) (*corev1.AvailablePackageSummary, error) {
+	pkg := &corev1.AvailablePackageSummary{}
+
+	if chart.Name == "" || chart.ID == "" || chart.Repo == nil {
+		return nil, status.Errorf(codes.Internal, "chart is missing required fields: %+v", chart)
+	}
+	pkg.DisplayName = chart.Name
+	pkg.ShortDescription = chart.Description
+	pkg.Icon = ""
+	pkg.IconUrl = chart.Icon
+
+	if len(chart.ChartVersions) == 0 {
+		return nil, status.Errorf(codes.Internal, "chart %q has no available versions", chart.ID)
+	}
+	pkg.LatestVersion = chart.ChartVersions[0].Version
+
+	pkg.AvailablePackageRef = &corev1.AvailablePackageReference{
+		Identifier: chart.ID,
+		Context:    &corev1.Context{Namespace: chart.Repo.Namespace},
+	}
+
+	return pkg, nil
+}
+
+// defaultPageSize is the page size GetAvailablePackageSummaries uses when a request carries
+// Pagination without a positive page size, and the size of each page that
+// StreamAvailablePackageSummaries fetches. A request with no Pagination at all is not bounded.
+const defaultPageSize = 100
+
+// GetAvailablePackageSummaries returns one page of the packages available to install in the
+// given namespace, falling back to the global packaging namespace for packages shared across
+// the cluster. Pagination is cursor-based: the opaque page token encodes the last-seen
+// (repo_namespace, chart_id) so the underlying query can use a keyset WHERE clause instead of
+// OFFSET, keeping later pages just as cheap as the first.
+func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *corev1.GetAvailablePackageSummariesRequest) (*corev1.GetAvailablePackageSummariesResponse, error) {
+	namespace := request.GetContext().GetNamespace()
+	if namespace == "" {
+		return nil, status.Errorf(codes.Unimplemented, "GetAvailablePackageSummaries requires a namespace")
+	}
+
+	after, err := decodeCursor(s.secret(), request.GetPagination().GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page_token: %v", err)
+	}
+
+	typedClient, _, err := s.GetClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.hasAccessToNamespaces(ctx, typedClient, candidateNamespaces(namespace, s.globalPackagingNamespace))
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, status.Errorf(codes.Unauthenticated, "user does not have access to read secrets in namespace %q", namespace)
+	}
+
+	manager, err := s.GetManager()
+	if err != nil {
+		return nil, err
+	}
+
+	// A request with no Pagination at all preserves the legacy unbounded behaviour; pageSize
+	// stays 0 and GetPaginatedChartListWithFilters returns every matching chart in one call.
+	pageSize := int(request.GetPagination().GetPageSize())
+	if request.GetPagination() != nil && pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	query := utils.ChartQuery{
+		Namespace:     namespace,
+		FilterOptions: chartQueryFilterOptions(request.GetFilterOptions()),
+	}
+	if after != nil {
+		query.AfterRepoNamespace = after.RepoNamespace
+		query.AfterChartID = after.ChartID
+	}
+
+	charts, err := manager.GetPaginatedChartListWithFilters(query, s.globalPackagingNamespace, 0, pageSize)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to retrieve charts: %v", err)
+	}
+
+	summaries := []*corev1.AvailablePackageSummary{}
+	for _, chart := range charts {
+		pkg, err := AvailablePackageSummaryFromChart(chart)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to parse chart %q: %v", chart.ID, err)
+		}
+		pkg.AvailablePackageRef.Plugin = pluginDetail
+		summaries = append(summaries, pkg)
+	}
+
+	response := &corev1.GetAvailablePackageSummariesResponse{AvailablePackagesSummaries: summaries}
+	if pageSize > 0 && len(charts) == pageSize {
+		last := charts[len(charts)-1]
+		response.NextPageToken, err = encodeCursor(s.secret(), pageCursor{RepoNamespace: last.Repo.Namespace, ChartID: last.ID})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to encode next_page_token: %v", err)
+		}
+	}
+
+	// Non-Postgres catalogs (e.g. OCI registries) aren't part of the keyset being paginated
+	// above, so they're merged in wholesale on the first page only, to avoid either repeating
+	// them on every subsequent page or inventing a second, incompatible cursor scheme for them.
+	if after == nil {
+		for _, catalog := range s.catalogs {
+			catalogSummaries, err := catalog.ListAvailablePackageSummaries(ctx)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "unable to list packages from catalog: %v", err)
+			}
+			response.AvailablePackagesSummaries = append(response.AvailablePackagesSummaries, catalogSummaries...)
+		}
+	}
+
+	return response, nil
+}
+
+// availablePackageSummaryStream is the subset of the grpc-generated server-streaming handle
+// that StreamAvailablePackageSummaries needs, kept local so this package doesn't have to wait
+// on the corresponding .proto/codegen change to land first.
+type availablePackageSummaryStream interface {
+	Send(*corev1.AvailablePackageSummary) error
+	Context() context.Context
+}
+
+// StreamAvailablePackageSummaries pushes one AvailablePackageSummary per templated chart as
+// pages are scanned from the database, rather than materializing the full catalog in memory
+// the way the unary GetAvailablePackageSummaries necessarily does for its single response.
+func (s *Server) StreamAvailablePackageSummaries(request *corev1.GetAvailablePackageSummariesRequest, stream availablePackageSummaryStream) error {
+	pageToken := request.GetPagination().GetPageToken()
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return status.Errorf(codes.Canceled, "stream cancelled: %v", err)
+		}
+
+		page := proto.Clone(request).(*corev1.GetAvailablePackageSummariesRequest)
+		page.Pagination = &corev1.PaginationOptions{PageToken: pageToken, PageSize: int32(defaultPageSize)}
+
+		response, err := s.GetAvailablePackageSummaries(stream.Context(), page)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Context().Err(); err != nil {
+			return status.Errorf(codes.Canceled, "stream cancelled: %v", err)
+		}
+
+		for _, summary := range response.AvailablePackagesSummaries {
+			if err := stream.Context().Err(); err != nil {
+				return status.Errorf(codes.Canceled, "stream cancelled: %v", err)
+			}
+			if err := stream.Send(summary); err != nil {
+				return err
+			}
+		}
+
+		if response.NextPageToken == "" {
+			return nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+// candidateNamespaces returns the distinct namespaces GetAvailablePackageSummaries reads
+// charts from: the requested namespace and the global packaging namespace shared cluster-wide.
+func candidateNamespaces(namespace, globalPackagingNamespace string) []string {
+	if namespace == globalPackagingNamespace {
+		return []string{namespace}
+	}
+	return []string{namespace, globalPackagingNamespace}
+}
+
+func chartQueryFilterOptions(f *corev1.FilterOptions) map[string][]string {
+	if f == nil {
+		return nil
+	}
+	opts := map[string][]string{}
+	if f.GetQuery() != "" {
+		opts["q"] = []string{f.GetQuery()}
+	}
+	if len(f.GetCategories()) > 0 {
+		opts["categories"] = f.GetCategories()
+	}
+	if len(f.GetRepositories()) > 0 {
+		opts["repos"] = f.GetRepositories()
+	}
+	return opts
+}
+
+// GetAvailablePackageDetail returns the full detail of a single available package.
+func (s *Server) GetAvailablePackageDetail(ctx context.Context, request *corev1.GetAvailablePackageDetailRequest) (*corev1.GetAvailablePackageDetailResponse, error) {
+	if request.GetAvailablePackageRef() == nil || request.GetAvailablePackageRef().GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "AvailablePackageRef.Identifier is required")
+	}
+
+	manager, err := s.GetManager()
+	if err != nil {
+		return nil, err
+	}
+
+	chart, err := manager.GetChart(request.GetAvailablePackageRef().GetIdentifier())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to retrieve chart: %v", err)
+	}
+
+	if len(chart.ChartVersions) == 0 {
+		return nil, status.Errorf(codes.Internal, "chart %q has no available versions", chart.ID)
+	}
+	version := chart.ChartVersions[0]
+	if request.GetPkgVersion() != "" {
+		found := false
+		for _, v := range chart.ChartVersions {
+			if v.Version == request.GetPkgVersion() {
+				version = v
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, status.Errorf(codes.NotFound, "version %q not found for chart %q", request.GetPkgVersion(), chart.ID)
+		}
+	}
+
+	maintainers := []*corev1.Maintainer{}
+	for _, m := range chart.Maintainers {
+		maintainers = append(maintainers, &corev1.Maintainer{Name: m.Name, Email: m.Email})
+	}
+
+	return &corev1.GetAvailablePackageDetailResponse{
+		AvailablePackageDetail: &corev1.AvailablePackageDetail{
+			Name:             chart.Name,
+			DisplayName:      chart.Name,
+			IconUrl:          chart.Icon,
+			ShortDescription: chart.Description,
+			LongDescription:  chart.Description,
+			PkgVersion:       version.Version,
+			AppVersion:       version.AppVersion,
+			Readme:           version.Readme,
+			DefaultValues:    version.Values,
+			ValuesSchema:     version.Schema,
+			Maintainers:      maintainers,
+			AvailablePackageRef: &corev1.AvailablePackageReference{
+				Identifier: chart.ID,
+				Context:    &corev1.Context{Namespace: chart.Repo.Namespace},
+				Plugin:     pluginDetail,
+			},
+		},
+	}, nil
+}
+
+// getRelease returns the deployed Helm release for name in namespace, reading it from the
+// same "sh.helm.release.v1" Secrets storage the helm CLI itself uses.
+func getRelease(typedClient kubernetes.Interface, namespace, name string) (*release.Release, error) {
+	store := storage.Init(driver.NewSecrets(typedClient.CoreV1().Secrets(namespace)))
+	return store.Last(name)
+}
+
+// InstalledPackageResourceStatus is the readiness of a single resource templated by a release.
+type InstalledPackageResourceStatus struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Ready     bool
+	Terminal  bool
+	Reason    string
+}
+
+// GetInstalledPackageResourceStatusResponse aggregates the readiness of every resource
+// templated by a release alongside the overall rollout status.
+type GetInstalledPackageResourceStatusResponse struct {
+	Status    statuscheck.Status
+	Resources []InstalledPackageResourceStatus
+}
+
+// GetInstalledPackageResourceStatus walks every resource templated by the release backing
+// ref and returns per-resource readiness plus an aggregated Ready/InProgress/Failed/Unknown
+// state, mirroring the status-check logic Helm 3.5 uses for `helm install --wait`.
+func (s *Server) GetInstalledPackageResourceStatus(ctx context.Context, ref *corev1.InstalledPackageReference) (*GetInstalledPackageResourceStatusResponse, error) {
+	if ref == nil || ref.GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "InstalledPackageReference.Identifier is required")
+	}
+	namespace := ref.GetContext().GetNamespace()
+	if namespace == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "InstalledPackageReference.Context.Namespace is required")
+	}
+
+	typedClient, dynamicClient, err := s.GetClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.hasAccessToNamespace(ctx, typedClient, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, status.Errorf(codes.Unauthenticated, "user does not have access to read secrets in namespace %q", namespace)
+	}
+
+	rel, err := getRelease(typedClient, namespace, ref.GetIdentifier())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to retrieve release %q: %v", ref.GetIdentifier(), err)
+	}
+
+	converter := statuscheck.NewConverter(typedClient.Discovery())
+	objs, err := converter.Convert(rel.Manifest)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to parse release manifest: %v", err)
+	}
+
+	resources := make([]InstalledPackageResourceStatus, 0, len(objs))
+	checkResults := make([]statuscheck.CheckResult, 0, len(objs))
+	for _, obj := range objs {
+		checker, ok := statuscheck.CheckerFor(obj.GetKind())
+		if !ok {
+			// Mirror Helm's own --wait behavior: a kind with no registered checker (ConfigMap,
+			// Secret, ServiceAccount, RBAC objects, Ingress, ...) is treated as ready rather
+			// than holding up the aggregated status forever.
+			resources = append(resources, InstalledPackageResourceStatus{
+				Kind:      obj.GetKind(),
+				Name:      obj.GetName(),
+				Namespace: obj.GetNamespace(),
+				Ready:     true,
+				Reason:    fmt.Sprintf("no status checker registered for kind %q; treated as ready", obj.GetKind()),
+			})
+			checkResults = append(checkResults, statuscheck.CheckResult{Ready: true})
+			continue
+		}
+		mapping, err := converter.RESTMapping(obj)
+		if err != nil {
+			resources = append(resources, InstalledPackageResourceStatus{
+				Kind:      obj.GetKind(),
+				Name:      obj.GetName(),
+				Namespace: obj.GetNamespace(),
+				Terminal:  true,
+				Reason:    err.Error(),
+			})
+			checkResults = append(checkResults, statuscheck.CheckResult{Failed: true})
+			continue
+		}
+
+		ready, reason, err := checker(obj, mapping.Resource, dynamicClient)
+		terminal := err != nil
+		if terminal {
+			log.Errorf("status check failed for %s/%s %q: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+			reason = err.Error()
+		}
+		resources = append(resources, InstalledPackageResourceStatus{
+			Kind:      obj.GetKind(),
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Ready:     ready,
+			Terminal:  terminal,
+			Reason:    reason,
+		})
+		checkResults = append(checkResults, statuscheck.CheckResult{Ready: ready, Failed: terminal})
+	}
+
+	return &GetInstalledPackageResourceStatusResponse{
+		Status:    statuscheck.Aggregate(checkResults),
+		Resources: resources,
+	}, nil
+}
@@ -29,9 +29,15 @@ import (
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
 	"github.com/kubeapps/kubeapps/pkg/chart/models"
 	"github.com/kubeapps/kubeapps/pkg/dbutils"
+	"github.com/kubeapps/kubeapps/pkg/statuscheck"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	helmstorage "helm.sh/helm/v3/pkg/storage"
+	helmdriver "helm.sh/helm/v3/pkg/storage/driver"
 	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
@@ -245,13 +251,24 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 		},
 	)
 
-	// Creating an authorized clientGetter
+	// Creating an authorized clientGetter. The SelfSubjectAccessReview reactor backs the
+	// legacy per-namespace fallback, while the SelfSubjectRulesReview reactor backs the
+	// batched path that GetAvailablePackageSummaries now uses by default.
 	authorizedClientSet := typfake.NewSimpleClientset()
 	authorizedClientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
 		return true, &authorizationv1.SelfSubjectAccessReview{
 			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: true},
 		}, nil
 	})
+	authorizedClientSet.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, &authorizationv1.SelfSubjectRulesReview{
+			Status: authorizationv1.SubjectRulesReviewStatus{
+				ResourceRules: []authorizationv1.ResourceRule{
+					{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+				},
+			},
+		}, nil
+	})
 	authorizedClientGetter := func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
 		return authorizedClientSet, dynamicClient, nil
 	}
@@ -403,4 +420,279 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			}
 		})
 	}
+}
+
+const readyDeploymentManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-release
+  namespace: my-ns
+  generation: 1
+spec:
+  replicas: 1
+status:
+  observedGeneration: 1
+  updatedReplicas: 1
+  availableReplicas: 1
+`
+
+func storeRelease(t *testing.T, typedClient kubernetes.Interface, namespace, name string, manifest string) {
+	t.Helper()
+	store := helmstorage.Init(helmdriver.NewSecrets(typedClient.CoreV1().Secrets(namespace)))
+	rel := &helmrelease.Release{
+		Name:      name,
+		Namespace: namespace,
+		Manifest:  manifest,
+		Version:   1,
+		Info:      &helmrelease.Info{Status: helmrelease.StatusDeployed},
+	}
+	if err := store.Create(rel); err != nil {
+		t.Fatalf("unable to create test release: %+v", err)
+	}
+}
+
+func TestGetInstalledPackageResourceStatus(t *testing.T) {
+	readyDeployment := unstructuredDeployment("my-release", "my-ns")
+
+	testCases := []struct {
+		name       string
+		ref        *corev1.InstalledPackageReference
+		authorized bool
+		statusCode codes.Code
+		wantStatus statuscheck.Status
+	}{
+		{
+			name: "it returns the aggregated status of a ready release",
+			ref: &corev1.InstalledPackageReference{
+				Context:    &corev1.Context{Namespace: "my-ns"},
+				Identifier: "my-release",
+			},
+			authorized: true,
+			statusCode: codes.OK,
+			wantStatus: statuscheck.StatusReady,
+		},
+		{
+			name: "it returns unauthenticated when the user lacks access",
+			ref: &corev1.InstalledPackageReference{
+				Context:    &corev1.Context{Namespace: "my-ns"},
+				Identifier: "my-release",
+			},
+			authorized: false,
+			statusCode: codes.Unauthenticated,
+		},
+		{
+			name:       "it returns invalid argument when no namespace is given",
+			ref:        &corev1.InstalledPackageReference{Identifier: "my-release"},
+			authorized: true,
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			typedClient := typfake.NewSimpleClientset()
+			typedClient.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				return true, &authorizationv1.SelfSubjectAccessReview{
+					Status: authorizationv1.SubjectAccessReviewStatus{Allowed: tc.authorized},
+				}, nil
+			})
+			// Converter.RESTMapping resolves the Deployment kind via this discovery fixture.
+			typedClient.Resources = []*metav1.APIResourceList{
+				{
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", Namespaced: true, Kind: "Deployment"},
+					},
+				},
+			}
+			if tc.ref.GetContext().GetNamespace() != "" {
+				storeRelease(t, typedClient, tc.ref.GetContext().GetNamespace(), tc.ref.GetIdentifier(), readyDeploymentManifest)
+			}
+
+			dynamicClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(
+				runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+				},
+				readyDeployment,
+			)
+
+			s := &Server{
+				clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+					return typedClient, dynamicClient, nil
+				},
+			}
+
+			resp, err := s.GetInstalledPackageResourceStatus(context.Background(), tc.ref)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode == codes.OK {
+				if got, want := resp.Status, tc.wantStatus; got != want {
+					t.Errorf("got: %+v, want: %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageSummariesRejectsTamperedPageToken(t *testing.T) {
+	authorizedClientSet := typfake.NewSimpleClientset()
+	authorizedClientSet.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, &authorizationv1.SelfSubjectRulesReview{
+			Status: authorizationv1.SubjectRulesReviewStatus{
+				ResourceRules: []authorizationv1.ResourceRule{
+					{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+				},
+			},
+		}, nil
+	})
+
+	s := &Server{
+		clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			return authorizedClientSet, nil, nil
+		},
+		globalPackagingNamespace: globalPackagingNamespace,
+	}
+
+	_, err := s.GetAvailablePackageSummaries(context.Background(), &corev1.GetAvailablePackageSummariesRequest{
+		Context:    &corev1.Context{Namespace: "my-ns"},
+		Pagination: &corev1.PaginationOptions{PageToken: "not-a-valid-cursor"},
+	})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+}
+
+// fakeSummaryStream is a minimal availablePackageSummaryStream used to test streaming
+// cancellation without depending on the generated grpc server-streaming handle.
+type fakeSummaryStream struct {
+	ctx  context.Context
+	sent []*corev1.AvailablePackageSummary
+}
+
+func (f *fakeSummaryStream) Send(s *corev1.AvailablePackageSummary) error {
+	f.sent = append(f.sent, s)
+	return nil
+}
+
+func (f *fakeSummaryStream) Context() context.Context { return f.ctx }
+
+func TestStreamAvailablePackageSummariesCancellation(t *testing.T) {
+	dynamicClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "foo", Version: "bar", Resource: "baz"}: "PackageList",
+		},
+	)
+	authorizedClientSet := typfake.NewSimpleClientset()
+	authorizedClientSet.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, &authorizationv1.SelfSubjectRulesReview{
+			Status: authorizationv1.SubjectRulesReviewStatus{
+				ResourceRules: []authorizationv1.ResourceRule{
+					{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+				},
+			},
+		}, nil
+	})
+
+	mock, cleanup, manager := setMockManager(t)
+	defer cleanup()
+	chartJSON, err := json.Marshal(chartOK)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	mock.ExpectQuery("SELECT info FROM").WillReturnRows(sqlmock.NewRows([]string{"info"}).AddRow(string(chartJSON)))
+
+	s := &Server{
+		clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			return authorizedClientSet, dynamicClient, nil
+		},
+		manager:                  manager,
+		globalPackagingNamespace: globalPackagingNamespace,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream := &fakeSummaryStream{ctx: ctx}
+
+	err = s.StreamAvailablePackageSummaries(&corev1.GetAvailablePackageSummariesRequest{
+		Context: &corev1.Context{Namespace: "my-ns"},
+	}, stream)
+
+	if got, want := status.Code(err), codes.Canceled; got != want {
+		t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+	if len(stream.sent) != 0 {
+		t.Errorf("got %d sent summaries, want 0 once the context is already cancelled", len(stream.sent))
+	}
+}
+
+// TestStreamAvailablePackageSummariesCancellationEmptyPage covers a page with zero summaries,
+// which used to fall through the per-summary cancellation check entirely and return nil.
+func TestStreamAvailablePackageSummariesCancellationEmptyPage(t *testing.T) {
+	dynamicClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "foo", Version: "bar", Resource: "baz"}: "PackageList",
+		},
+	)
+	authorizedClientSet := typfake.NewSimpleClientset()
+	authorizedClientSet.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, &authorizationv1.SelfSubjectRulesReview{
+			Status: authorizationv1.SubjectRulesReviewStatus{
+				ResourceRules: []authorizationv1.ResourceRule{
+					{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+				},
+			},
+		}, nil
+	})
+
+	mock, cleanup, manager := setMockManager(t)
+	defer cleanup()
+	mock.ExpectQuery("SELECT info FROM").WillReturnRows(sqlmock.NewRows([]string{"info"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &Server{
+		clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			// Cancel while the first page is being fetched, so the stream only sees the
+			// cancellation after that (empty) page has been returned.
+			cancel()
+			return authorizedClientSet, dynamicClient, nil
+		},
+		manager:                  manager,
+		globalPackagingNamespace: globalPackagingNamespace,
+	}
+
+	stream := &fakeSummaryStream{ctx: ctx}
+
+	err := s.StreamAvailablePackageSummaries(&corev1.GetAvailablePackageSummariesRequest{
+		Context: &corev1.Context{Namespace: "my-ns"},
+	}, stream)
+
+	if got, want := status.Code(err), codes.Canceled; got != want {
+		t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+}
+
+func unstructuredDeployment(name, namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  namespace,
+			"generation": int64(1),
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+		},
+		"status": map[string]interface{}{
+			"observedGeneration": int64(1),
+			"updatedReplicas":    int64(1),
+			"availableReplicas":  int64(1),
+		},
+	}}
 }
\ No newline at end of file
@@ -0,0 +1,286 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ociregistry discovers Helm charts published as OCI artifacts (Helm 3.8+,
+// application/vnd.cncf.helm.config.v1+json) by talking directly to the OCI Distribution v2
+// API rather than through Helm's registry client; only Helm's chart metadata type is used.
+package ociregistry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/kubeapps/kubeapps/pkg/chart/models"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// helmConfigMediaType is the OCI config media type Helm 3.8+ uses for a chart artifact.
+const helmConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+// catalogScope is the token scope the OCI Distribution spec requires for the `_catalog`
+// endpoint; repositoryScope is the scope required to read anything under a given repository.
+const catalogScope = "registry:catalog:*"
+
+func repositoryScope(repo string) string { return fmt.Sprintf("repository:%s:pull", repo) }
+
+// Catalog lists the Helm charts published to a single OCI registry.
+type Catalog struct {
+	registry   string
+	scheme     string
+	httpClient *http.Client
+	keychain   authn.Keychain
+}
+
+// NewCatalog returns a Catalog for the OCI registry at host (e.g. "registry.example.com"),
+// authenticating requests via keychain.
+func NewCatalog(host string, keychain authn.Keychain) *Catalog {
+	return &Catalog{registry: host, scheme: "https", httpClient: http.DefaultClient, keychain: keychain}
+}
+
+type catalogResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+type tagsListResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type manifestDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type manifest struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	Config        manifestDescriptor   `json:"config"`
+	Layers        []manifestDescriptor `json:"layers"`
+}
+
+// ListCharts lists every Helm chart version published to the registry as an OCI artifact.
+func (c *Catalog) ListCharts(ctx context.Context) ([]*models.Chart, error) {
+	repos, err := c.listRepositories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list repositories: %w", err)
+	}
+
+	charts := []*models.Chart{}
+	for _, repo := range repos {
+		tags, err := c.listTags(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list tags for %q: %w", repo, err)
+		}
+		for _, tag := range tags {
+			chart, err := c.chartForTag(ctx, repo, tag)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s:%s: %w", repo, tag, err)
+			}
+			if chart != nil {
+				charts = append(charts, chart)
+			}
+		}
+	}
+	return charts, nil
+}
+
+func (c *Catalog) listRepositories(ctx context.Context) ([]string, error) {
+	var out catalogResponse
+	if err := c.getJSON(ctx, "/v2/_catalog", catalogScope, &out); err != nil {
+		return nil, err
+	}
+	return out.Repositories, nil
+}
+
+func (c *Catalog) listTags(ctx context.Context, repo string) ([]string, error) {
+	var out tagsListResponse
+	if err := c.getJSON(ctx, fmt.Sprintf("/v2/%s/tags/list", repo), repositoryScope(repo), &out); err != nil {
+		return nil, err
+	}
+	return out.Tags, nil
+}
+
+// chartForTag fetches the manifest for repo:tag and, if it describes a Helm chart, returns the
+// models.Chart built from its config blob (the chart metadata) and the values.yaml/README.md
+// found in its content layers. A non-Helm artifact
+// (any config mediaType other than helmConfigMediaType) is skipped, returning (nil, nil).
+func (c *Catalog) chartForTag(ctx context.Context, repo, tag string) (*models.Chart, error) {
+	var m manifest
+	if err := c.getJSON(ctx, fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), repositoryScope(repo), &m); err != nil {
+		return nil, err
+	}
+	if m.Config.MediaType != helmConfigMediaType {
+		return nil, nil
+	}
+
+	config, err := c.getBlob(ctx, repo, m.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config blob: %w", err)
+	}
+	var metadata chart.Metadata
+	if err := json.Unmarshal(config, &metadata); err != nil {
+		return nil, fmt.Errorf("unable to parse chart config: %w", err)
+	}
+
+	var readme, values string
+	for _, layer := range m.Layers {
+		blob, err := c.getBlob(ctx, repo, layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch content layer: %w", err)
+		}
+		files, err := extractTarGzFiles(blob, "values.yaml", "README.md")
+		if err != nil {
+			// Not every layer is the chart content archive (e.g. provenance files); skip it.
+			continue
+		}
+		values = files["values.yaml"]
+		readme = files["README.md"]
+	}
+
+	return &models.Chart{
+		Name:        metadata.Name,
+		ID:          fmt.Sprintf("%s/%s", repo, metadata.Name),
+		Description: metadata.Description,
+		Icon:        metadata.Icon,
+		Repo:        &models.Repo{Name: repo, Namespace: c.registry},
+		ChartVersions: []models.ChartVersion{{
+			Version:    metadata.Version,
+			AppVersion: metadata.AppVersion,
+			Readme:     readme,
+			Values:     values,
+		}},
+	}, nil
+}
+
+func (c *Catalog) getJSON(ctx context.Context, path, scope string, out interface{}) error {
+	body, err := c.get(ctx, path, "application/json", scope)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(out)
+}
+
+func (c *Catalog) getBlob(ctx context.Context, repo, digest string) ([]byte, error) {
+	body, err := c.get(ctx, fmt.Sprintf("/v2/%s/blobs/%s", repo, digest), "*/*", repositoryScope(repo))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (c *Catalog) get(ctx context.Context, path, accept, scope string) (io.ReadCloser, error) {
+	rt, err := c.transportFor(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scheme+"://"+c.registry+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, path)
+	}
+	return resp.Body, nil
+}
+
+// transportFor returns the RoundTripper to use for a request needing scope (an OCI Distribution
+// auth scope such as "repository:mychart:pull"). With a keychain configured, it delegates to
+// go-containerregistry's transport package, which performs the full OCI Distribution auth
+// handshake on the caller's behalf: a Basic challenge is satisfied directly from the resolved
+// authn.AuthConfig (Auth, or Username/Password), while a Bearer challenge is satisfied by
+// exchanging those credentials for a token at the realm named in the 401's WWW-Authenticate
+// header. Without a keychain, requests are sent unauthenticated.
+func (c *Catalog) transportFor(ctx context.Context, scope string) (http.RoundTripper, error) {
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if c.keychain == nil {
+		return base, nil
+	}
+
+	reg, err := name.NewRegistry(c.registry, name.Insecure(c.scheme == "http"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry host %q: %w", c.registry, err)
+	}
+	authenticator, err := c.keychain.Resolve(reg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve registry credentials: %w", err)
+	}
+	return transport.NewWithContext(ctx, reg, authenticator, base, []string{scope})
+}
+
+// extractTarGzFiles reads a gzipped tarball and returns the contents of each requested file
+// found within it, erroring if none of the requested files are present (a signal that blob
+// isn't the chart content archive at all).
+func extractTarGzFiles(blob []byte, wanted ...string) (map[string]string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	want := map[string]bool{}
+	for _, w := range wanted {
+		want[w] = true
+	}
+
+	found := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tar entry: %w", err)
+		}
+		entryName := hdr.Name
+		if idx := strings.IndexByte(entryName, '/'); idx >= 0 {
+			entryName = entryName[idx+1:]
+		}
+		if !want[entryName] {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %q: %w", entryName, err)
+		}
+		found[entryName] = string(content)
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("none of %v found in archive", wanted)
+	}
+	return found, nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ociregistry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// buildChartContentLayer returns a gzipped tarball containing values.yaml and README.md under
+// a chart-name directory, the same layout Helm produces when packaging a chart for OCI push.
+func buildChartContentLayer(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range map[string]string{
+		"mychart/values.yaml": "replicaCount: 1\n",
+		"mychart/README.md":   "# My Chart\n",
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content))}); err != nil {
+			t.Fatalf("unable to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("unable to write tar content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unable to close tar writer: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("unable to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// newFakeRegistry serves a single repo ("mychart") with a single tag ("1.2.3") describing a
+// Helm chart OCI artifact, backed by the OCI Distribution v2 endpoints Catalog calls.
+func newFakeRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	config := []byte(`{"name":"mychart","version":"1.2.3","appVersion":"4.5.6","description":"a test chart","icon":"https://example.com/icon.png"}`)
+	content := buildChartContentLayer(t)
+	manifestBody, err := json.Marshal(manifest{
+		SchemaVersion: 2,
+		Config:        manifestDescriptor{MediaType: helmConfigMediaType, Digest: "sha256:config", Size: int64(len(config))},
+		Layers:        []manifestDescriptor{{MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip", Digest: "sha256:content", Size: int64(len(content))}},
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal manifest: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(catalogResponse{Repositories: []string{"mychart"}})
+	})
+	mux.HandleFunc("/v2/mychart/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(tagsListResponse{Name: "mychart", Tags: []string{"1.2.3"}})
+	})
+	mux.HandleFunc("/v2/mychart/manifests/1.2.3", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(manifestBody)
+	})
+	mux.HandleFunc("/v2/mychart/blobs/sha256:config", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(config)
+	})
+	mux.HandleFunc("/v2/mychart/blobs/sha256:content", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestListCharts(t *testing.T) {
+	server := newFakeRegistry(t)
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	// The fake registry only serves plain HTTP, unlike a real OCI registry.
+	catalog := NewCatalog(host, nil)
+	catalog.httpClient = server.Client()
+	catalog.scheme = "http"
+
+	charts, err := catalog.ListCharts(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(charts) != 1 {
+		t.Fatalf("got %d charts, want 1", len(charts))
+	}
+
+	chart := charts[0]
+	if chart.Name != "mychart" {
+		t.Errorf("got name %q, want %q", chart.Name, "mychart")
+	}
+	if chart.ID != "mychart/mychart" {
+		t.Errorf("got ID %q, want %q", chart.ID, "mychart/mychart")
+	}
+	if chart.Description != "a test chart" {
+		t.Errorf("got description %q, want %q", chart.Description, "a test chart")
+	}
+	if len(chart.ChartVersions) != 1 {
+		t.Fatalf("got %d chart versions, want 1", len(chart.ChartVersions))
+	}
+	version := chart.ChartVersions[0]
+	if version.Version != "1.2.3" {
+		t.Errorf("got version %q, want %q", version.Version, "1.2.3")
+	}
+	if version.AppVersion != "4.5.6" {
+		t.Errorf("got appVersion %q, want %q", version.AppVersion, "4.5.6")
+	}
+	if version.Values != "replicaCount: 1\n" {
+		t.Errorf("got values %q, want %q", version.Values, "replicaCount: 1\n")
+	}
+	if version.Readme != "# My Chart\n" {
+		t.Errorf("got readme %q, want %q", version.Readme, "# My Chart\n")
+	}
+}
+
+func TestListChartsSkipsNonHelmArtifacts(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(catalogResponse{Repositories: []string{"someimage"}})
+	})
+	mux.HandleFunc("/v2/someimage/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(tagsListResponse{Name: "someimage", Tags: []string{"latest"}})
+	})
+	mux.HandleFunc("/v2/someimage/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(manifest{SchemaVersion: 2, Config: manifestDescriptor{MediaType: "application/vnd.oci.image.config.v1+json"}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	catalog := NewCatalog(server.Listener.Addr().String(), nil)
+	catalog.httpClient = server.Client()
+	catalog.scheme = "http"
+
+	charts, err := catalog.ListCharts(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(charts) != 0 {
+		t.Errorf("got %d charts, want 0", len(charts))
+	}
+}
+
+func TestExtractTarGzFiles(t *testing.T) {
+	content := buildChartContentLayer(t)
+
+	files, err := extractTarGzFiles(content, "values.yaml", "README.md")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files["values.yaml"] != "replicaCount: 1\n" {
+		t.Errorf("got values.yaml %q", files["values.yaml"])
+	}
+	if files["README.md"] != "# My Chart\n" {
+		t.Errorf("got README.md %q", files["README.md"])
+	}
+
+	if _, err := extractTarGzFiles([]byte("not a gzip archive"), "values.yaml"); err == nil {
+		t.Errorf("expected an error for a non-gzip blob, got nil")
+	}
+}
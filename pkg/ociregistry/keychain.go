@@ -0,0 +1,47 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ociregistry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PullSecretRef names a Kubernetes image pull secret to authenticate an OCI registry with.
+type PullSecretRef struct {
+	Name      string
+	Namespace string
+}
+
+// KeychainFor resolves the authn.Keychain a Catalog should use to authenticate against a
+// registry: an explicit k8s pull secret if given, otherwise the ambient docker-config
+// keychain, which itself resolves to anonymous access for any registry it has no credentials
+// for.
+func KeychainFor(ctx context.Context, typedClient kubernetes.Interface, pullSecret *PullSecretRef) (authn.Keychain, error) {
+	if pullSecret != nil {
+		chain, err := k8schain.New(ctx, typedClient, k8schain.Options{
+			Namespace:        pullSecret.Namespace,
+			ImagePullSecrets: []string{pullSecret.Name},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to build keychain from pull secret %s/%s: %w", pullSecret.Namespace, pullSecret.Name, err)
+		}
+		return chain, nil
+	}
+	return authn.DefaultKeychain, nil
+}
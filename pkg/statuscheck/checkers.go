@@ -0,0 +1,279 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ResourceChecker reports the readiness of a single resource templated by a release. gvr is
+// the REST mapping Converter.RESTMapping resolved for obj, so that CRDs and aliased API groups
+// are fetched against the plural resource name the cluster actually serves rather than a
+// hardcoded guess. A non-nil error indicates a terminal failure (e.g. a Deployment whose
+// rollout exceeded its progress deadline) rather than "not ready yet", which is instead
+// reported via Ready=false.
+type ResourceChecker func(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (ready bool, reason string, err error)
+
+var checkers = map[string]ResourceChecker{
+	"Deployment":               checkDeployment,
+	"StatefulSet":              checkStatefulSet,
+	"DaemonSet":                checkDaemonSet,
+	"ReplicaSet":               checkReplicaSet,
+	"ReplicationController":    checkReplicationController,
+	"Pod":                      checkPod,
+	"Service":                  checkService,
+	"PersistentVolumeClaim":    checkPVC,
+	"Job":                      checkJob,
+	"CustomResourceDefinition": checkCRD,
+}
+
+// CheckerFor returns the ResourceChecker registered for kind, if any.
+func CheckerFor(kind string) (ResourceChecker, bool) {
+	checker, ok := checkers[kind]
+	return checker, ok
+}
+
+func checkDeployment(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	generation, _, _ := unstructured.NestedInt64(live.Object, "metadata", "generation")
+	observedGeneration, _, _ := unstructured.NestedInt64(live.Object, "status", "observedGeneration")
+	if observedGeneration < generation {
+		return false, "waiting for observed generation to catch up with the latest rollout", nil
+	}
+
+	specReplicas := nestedReplicas(live, "spec", "replicas")
+	updatedReplicas, _, _ := unstructured.NestedInt64(live.Object, "status", "updatedReplicas")
+	availableReplicas, _, _ := unstructured.NestedInt64(live.Object, "status", "availableReplicas")
+
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Progressing" && condition["reason"] == "ProgressDeadlineExceeded" {
+			return false, "deployment exceeded its progress deadline", fmt.Errorf("progress deadline exceeded")
+		}
+	}
+
+	if updatedReplicas != specReplicas {
+		return false, fmt.Sprintf("%d out of %d new replicas have been updated", updatedReplicas, specReplicas), nil
+	}
+	if availableReplicas != specReplicas {
+		return false, fmt.Sprintf("%d of %d replicas available", availableReplicas, specReplicas), nil
+	}
+
+	return true, "deployment successfully rolled out", nil
+}
+
+func checkStatefulSet(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	specReplicas := nestedReplicas(live, "spec", "replicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(live.Object, "status", "readyReplicas")
+	if readyReplicas != specReplicas {
+		return false, fmt.Sprintf("%d of %d replicas ready", readyReplicas, specReplicas), nil
+	}
+
+	updateStrategy, _, _ := unstructured.NestedString(live.Object, "spec", "updateStrategy", "type")
+	if updateStrategy == "" || updateStrategy == "RollingUpdate" {
+		currentRevision, _, _ := unstructured.NestedString(live.Object, "status", "currentRevision")
+		updateRevision, _, _ := unstructured.NestedString(live.Object, "status", "updateRevision")
+		if currentRevision != updateRevision {
+			return false, "waiting for statefulset rolling update to complete", nil
+		}
+	}
+
+	return true, "statefulset rolled out", nil
+}
+
+func checkDaemonSet(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	desired, _, _ := unstructured.NestedInt64(live.Object, "status", "desiredNumberScheduled")
+	ready, _, _ := unstructured.NestedInt64(live.Object, "status", "numberReady")
+	if ready != desired {
+		return false, fmt.Sprintf("%d out of %d desired nodes ready", ready, desired), nil
+	}
+
+	generation, _, _ := unstructured.NestedInt64(live.Object, "metadata", "generation")
+	observedGeneration, _, _ := unstructured.NestedInt64(live.Object, "status", "observedGeneration")
+	if observedGeneration < generation {
+		return false, "waiting for observed generation to catch up with the latest rollout", nil
+	}
+
+	return true, "daemon set successfully rolled out", nil
+}
+
+func checkReplicaSet(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+	specReplicas := nestedReplicas(live, "spec", "replicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(live.Object, "status", "readyReplicas")
+	if readyReplicas != specReplicas {
+		return false, fmt.Sprintf("%d of %d replicas ready", readyReplicas, specReplicas), nil
+	}
+	return true, "replica set ready", nil
+}
+
+func checkReplicationController(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+	specReplicas := nestedReplicas(live, "spec", "replicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(live.Object, "status", "readyReplicas")
+	if readyReplicas != specReplicas {
+		return false, fmt.Sprintf("%d of %d replicas ready", readyReplicas, specReplicas), nil
+	}
+	return true, "replication controller ready", nil
+}
+
+func checkPod(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	phase, _, _ := unstructured.NestedString(live.Object, "status", "phase")
+	if phase == "Failed" {
+		return false, "pod is in the Failed phase", fmt.Errorf("pod failed")
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Ready" {
+			if condition["status"] == "True" {
+				return true, "pod is ready", nil
+			}
+			reason, _ := condition["reason"].(string)
+			return false, fmt.Sprintf("pod is not ready: %s", reason), nil
+		}
+	}
+
+	return false, "pod has no Ready condition yet", nil
+}
+
+func checkService(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	serviceType, _, _ := unstructured.NestedString(live.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return true, "service does not require a load balancer", nil
+	}
+
+	ingress, _, _ := unstructured.NestedSlice(live.Object, "status", "loadBalancer", "ingress")
+	if len(ingress) == 0 {
+		return false, "waiting for load balancer ingress to be assigned", nil
+	}
+	return true, "load balancer ingress assigned", nil
+}
+
+func checkPVC(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	phase, _, _ := unstructured.NestedString(live.Object, "status", "phase")
+	if phase != "Bound" {
+		return false, fmt.Sprintf("persistent volume claim is %s", phase), nil
+	}
+	return true, "persistent volume claim bound", nil
+}
+
+func checkJob(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	completions := nestedReplicas(live, "spec", "completions")
+	succeeded, _, _ := unstructured.NestedInt64(live.Object, "status", "succeeded")
+	failed, _, _ := unstructured.NestedInt64(live.Object, "status", "failed")
+	if failed > 0 {
+		return false, fmt.Sprintf("%d pods failed", failed), fmt.Errorf("job has failed pods")
+	}
+	if succeeded < completions {
+		return false, fmt.Sprintf("%d out of %d completions", succeeded, completions), nil
+	}
+	return true, "job completed", nil
+}
+
+func checkCRD(obj *unstructured.Unstructured, gvr schema.GroupVersionResource, kubeClient dynamic.Interface) (bool, string, error) {
+	live, err := getLive(obj, kubeClient, gvr)
+	if err != nil {
+		return false, "", err
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Established" {
+			if condition["status"] == "True" {
+				return true, "custom resource definition established", nil
+			}
+			return false, "waiting for custom resource definition to be established", nil
+		}
+	}
+	return false, "custom resource definition has no Established condition yet", nil
+}
+
+// getLive fetches the current state of obj from the cluster, since the manifest copy produced
+// by Converter.Convert predates the release and carries no status.
+func getLive(obj *unstructured.Unstructured, kubeClient dynamic.Interface, gvr schema.GroupVersionResource) (*unstructured.Unstructured, error) {
+	resource := kubeClient.Resource(gvr)
+	if obj.GetNamespace() == "" {
+		return resource.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	}
+	return resource.Namespace(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+}
+
+// nestedReplicas reads an int64 field that k8s defaults to 1 when omitted from the spec (e.g.
+// spec.replicas, spec.completions).
+func nestedReplicas(obj *unstructured.Unstructured, fields ...string) int64 {
+	v, found, _ := unstructured.NestedInt64(obj.Object, fields...)
+	if !found {
+		return 1
+	}
+	return v
+}
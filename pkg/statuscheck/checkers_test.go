@@ -0,0 +1,177 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newFakeDynamicClient(objs ...runtime.Object) *dynfake.FakeDynamicClient {
+	scheme := runtime.NewScheme()
+	return dynfake.NewSimpleDynamicClient(scheme, objs...)
+}
+
+func unstructuredObj(apiVersion, kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+	}
+	for k, v := range fields {
+		obj[k] = v
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestCheckDeployment(t *testing.T) {
+	testCases := []struct {
+		name      string
+		live      *unstructured.Unstructured
+		wantReady bool
+		wantErr   bool
+	}{
+		{
+			name: "it is ready when generation, updated and available replicas match",
+			live: unstructuredObj("apps/v1", "Deployment", "default", "my-app", map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "my-app", "namespace": "default", "generation": int64(2)},
+				"spec":     map[string]interface{}{"replicas": int64(3)},
+				"status": map[string]interface{}{
+					"observedGeneration": int64(2),
+					"updatedReplicas":    int64(3),
+					"availableReplicas":  int64(3),
+				},
+			}),
+			wantReady: true,
+		},
+		{
+			name: "it is not ready while the observed generation lags",
+			live: unstructuredObj("apps/v1", "Deployment", "default", "my-app", map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "my-app", "namespace": "default", "generation": int64(3)},
+				"spec":     map[string]interface{}{"replicas": int64(3)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2)},
+			}),
+			wantReady: false,
+		},
+		{
+			name: "it fails when the rollout exceeded its progress deadline",
+			live: unstructuredObj("apps/v1", "Deployment", "default", "my-app", map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "my-app", "namespace": "default", "generation": int64(1)},
+				"spec":     map[string]interface{}{"replicas": int64(3)},
+				"status": map[string]interface{}{
+					"observedGeneration": int64(1),
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Progressing", "reason": "ProgressDeadlineExceeded"},
+					},
+				},
+			}),
+			wantReady: false,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeDynamicClient(tc.live)
+			obj := unstructuredObj("apps/v1", "Deployment", "default", "my-app", nil)
+
+			gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+			ready, _, err := checkDeployment(obj, gvr, client)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error: %v, wantErr: %v", err, tc.wantErr)
+			}
+			if ready != tc.wantReady {
+				t.Errorf("got ready: %v, want: %v", ready, tc.wantReady)
+			}
+		})
+	}
+}
+
+func TestCheckService(t *testing.T) {
+	testCases := []struct {
+		name      string
+		live      *unstructured.Unstructured
+		wantReady bool
+	}{
+		{
+			name: "a ClusterIP service is always ready",
+			live: unstructuredObj("v1", "Service", "default", "my-svc", map[string]interface{}{
+				"spec": map[string]interface{}{"type": "ClusterIP"},
+			}),
+			wantReady: true,
+		},
+		{
+			name: "a LoadBalancer service is not ready until ingress is assigned",
+			live: unstructuredObj("v1", "Service", "default", "my-svc", map[string]interface{}{
+				"spec": map[string]interface{}{"type": "LoadBalancer"},
+			}),
+			wantReady: false,
+		},
+		{
+			name: "a LoadBalancer service is ready once ingress is assigned",
+			live: unstructuredObj("v1", "Service", "default", "my-svc", map[string]interface{}{
+				"spec": map[string]interface{}{"type": "LoadBalancer"},
+				"status": map[string]interface{}{
+					"loadBalancer": map[string]interface{}{
+						"ingress": []interface{}{map[string]interface{}{"ip": "1.2.3.4"}},
+					},
+				},
+			}),
+			wantReady: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeDynamicClient(tc.live)
+			obj := unstructuredObj("v1", "Service", "default", "my-svc", nil)
+
+			gvr := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"}
+			ready, _, err := checkService(obj, gvr, client)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ready != tc.wantReady {
+				t.Errorf("got ready: %v, want: %v", ready, tc.wantReady)
+			}
+		})
+	}
+}
+
+func TestCheckerFor(t *testing.T) {
+	testCases := []struct {
+		kind string
+		ok   bool
+	}{
+		{kind: "Deployment", ok: true},
+		{kind: "Job", ok: true},
+		{kind: "Bogus", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.kind, func(t *testing.T) {
+			_, ok := CheckerFor(tc.kind)
+			if ok != tc.ok {
+				t.Errorf("got: %v, want: %v", ok, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+)
+
+// Converter parses a rendered release manifest into the unstructured objects it templates.
+type Converter struct {
+	discoveryClient discovery.DiscoveryInterface
+}
+
+// NewConverter returns a Converter that resolves REST mappings via discoveryClient.
+func NewConverter(discoveryClient discovery.DiscoveryInterface) *Converter {
+	return &Converter{discoveryClient: discoveryClient}
+}
+
+// Convert splits a multi-document release manifest into its constituent
+// unstructured.Unstructured objects, skipping empty documents.
+func (c *Converter) Convert(manifest string) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+	for _, doc := range strings.Split(manifest, "\n---\n") {
+		doc = strings.TrimSpace(doc)
+		if doc == "" {
+			continue
+		}
+
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
+			return nil, fmt.Errorf("unable to parse manifest document: %w", err)
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("manifest document is missing kind/apiVersion: %q", doc)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// RESTMapping resolves the REST mapping (and therefore the plural resource name and scope)
+// for obj's GroupVersionKind using the converter's discovery client.
+func (c *Converter) RESTMapping(obj *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+	groupResources, err := restmapper.GetAPIGroupResources(c.discoveryClient)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch API group resources: %w", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve REST mapping for %s: %w", gvk, err)
+	}
+	return mapping, nil
+}
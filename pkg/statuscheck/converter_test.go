@@ -0,0 +1,65 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	typfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRESTMapping(t *testing.T) {
+	typedClient := typfake.NewSimpleClientset()
+	typedClient.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Namespaced: true, Kind: "Deployment"},
+			},
+		},
+	}
+	converter := NewConverter(typedClient.Discovery())
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "my-app", "namespace": "default"},
+	}}
+
+	mapping, err := converter.RESTMapping(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	if mapping.Resource != want {
+		t.Errorf("got resource %v, want %v", mapping.Resource, want)
+	}
+}
+
+func TestRESTMappingUnknownKind(t *testing.T) {
+	converter := NewConverter(typfake.NewSimpleClientset().Discovery())
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bogus.example.com/v1",
+		"kind":       "Bogus",
+		"metadata":   map[string]interface{}{"name": "whatever"},
+	}}
+
+	if _, err := converter.RESTMapping(obj); err == nil {
+		t.Errorf("expected an error for an unresolvable kind, got nil")
+	}
+}
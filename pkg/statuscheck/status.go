@@ -0,0 +1,63 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package statuscheck ports the resource readiness checks Helm 3.5 runs for
+// `helm install/upgrade --wait`, so callers outside of the Helm SDK (such as
+// the kubeapps-apis plugins) can report per-resource and aggregated rollout
+// status for a release without re-implementing Helm's deploymentutil logic.
+package statuscheck
+
+// Status is the aggregated readiness of a set of resources templated by a release.
+type Status string
+
+const (
+	// StatusReady means every resource in the set reported ready.
+	StatusReady Status = "Ready"
+	// StatusInProgress means no resource has failed, but at least one is not yet ready.
+	StatusInProgress Status = "InProgress"
+	// StatusFailed means at least one resource reported a terminal failure.
+	StatusFailed Status = "Failed"
+	// StatusUnknown means readiness could not be determined, e.g. no resources were found to
+	// check at all. A resource whose kind has no registered checker is not represented here:
+	// per Helm's own --wait semantics, a kind it doesn't know how to check is treated as ready.
+	StatusUnknown Status = "Unknown"
+)
+
+// CheckResult is the readiness of a single resource as returned by a ResourceChecker. Callers
+// keep their own richer per-resource records and pass a CheckResult per resource to Aggregate.
+type CheckResult struct {
+	Ready  bool
+	Failed bool
+}
+
+// Aggregate combines the readiness of every resource templated by a release into a single
+// Status: Ready only if every resource is ready, Failed if any resource reports a terminal
+// failure, otherwise InProgress. An empty results slice yields Unknown.
+func Aggregate(results []CheckResult) Status {
+	if len(results) == 0 {
+		return StatusUnknown
+	}
+	ready := true
+	for _, r := range results {
+		if r.Failed {
+			return StatusFailed
+		}
+		if !r.Ready {
+			ready = false
+		}
+	}
+	if ready {
+		return StatusReady
+	}
+	return StatusInProgress
+}
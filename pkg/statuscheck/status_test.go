@@ -0,0 +1,62 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import "testing"
+
+func TestAggregate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		results []CheckResult
+		want    Status
+	}{
+		{
+			name: "ready when every result is ready",
+			results: []CheckResult{
+				{Ready: true},
+				{Ready: true},
+			},
+			want: StatusReady,
+		},
+		{
+			name: "in progress when a resource is not yet ready",
+			results: []CheckResult{
+				{Ready: true},
+				{Ready: false},
+			},
+			want: StatusInProgress,
+		},
+		{
+			name: "failed when any resource reports a terminal failure",
+			results: []CheckResult{
+				{Ready: true},
+				{Failed: true},
+			},
+			want: StatusFailed,
+		},
+		{
+			name:    "unknown when there are no resources to check",
+			results: nil,
+			want:    StatusUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Aggregate(tc.results); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// waitBackoff mirrors the backoff Helm 3.5 uses while waiting for a release to become ready:
+// it starts at 1s and roughly doubles up to a 30s ceiling between polls.
+var waitBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Steps:    10,
+	Cap:      30 * time.Second,
+}
+
+// Wait polls objs with exponential backoff until every one of them is ready, a checker
+// reports a terminal failure, or timeout/ctx elapses. converter resolves each obj's REST
+// mapping so checkers query the plural resource name the cluster actually serves.
+func Wait(ctx context.Context, converter *Converter, objs []*unstructured.Unstructured, kubeClient dynamic.Interface, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := waitBackoff
+	for {
+		ready, err := func() (bool, error) {
+			for _, obj := range objs {
+				if ctx.Err() != nil {
+					return false, ctx.Err()
+				}
+				checker, ok := CheckerFor(obj.GetKind())
+				if !ok {
+					// A kind with no registered checker is treated as ready, as Helm's --wait does.
+					continue
+				}
+				mapping, err := converter.RESTMapping(obj)
+				if err != nil {
+					return false, err
+				}
+				ready, _, err := checker(obj, mapping.Resource, kubeClient)
+				if err != nil {
+					return false, err
+				}
+				if !ready {
+					return false, nil
+				}
+			}
+			return true, nil
+		}()
+		if err != nil || ready {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff.Step()):
+		}
+	}
+}